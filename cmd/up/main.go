@@ -6,6 +6,7 @@ import (
 	"github.com/alecthomas/kong"
 
 	"github.com/upbound/up/cmd/up/cloud"
+	"github.com/upbound/up/cmd/up/sos"
 	"github.com/upbound/up/internal/version"
 )
 
@@ -25,6 +26,7 @@ var cli struct {
 	Version versionFlag `short:"v" name:"version" help:"Print version and exit."`
 
 	Cloud cloud.Cmd `cmd:"" help:"Interact with Upbound Cloud."`
+	Sos   sos.Cmd   `cmd:"" help:"Export and import the full state of a control plane."`
 }
 
 func main() {
@@ -34,4 +36,4 @@ func main() {
 		kong.UsageOnError())
 	err := ctx.Run()
 	ctx.FatalIfErrorf(err)
-}
\ No newline at end of file
+}
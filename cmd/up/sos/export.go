@@ -0,0 +1,87 @@
+// Copyright 2024 Upbound Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sos
+
+import (
+	"context"
+
+	"github.com/crossplane/crossplane-runtime/pkg/errors"
+	"github.com/pterm/pterm"
+	apiextensionsclientset "k8s.io/apiextensions-apiserver/pkg/client/clientset/clientset"
+	"k8s.io/client-go/discovery/cached/memory"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/restmapper"
+
+	"github.com/upbound/up/internal/kube"
+	"github.com/upbound/up/internal/sos/exporter"
+)
+
+// ExportCmd exports the full state of a control plane to a local archive or
+// to object storage.
+type ExportCmd struct {
+	Kubeconfig string `type:"path" help:"Path to the kubeconfig of the control plane to export. Defaults to the current kubeconfig context."`
+	Output     string `short:"o" default:"export.tar.gz" help:"Destination the export is written to: a local path, or a URL with an s3://, gs://, zip:// or dir:// scheme."`
+
+	IncludeGroups     []string `help:"Only export resources whose API group matches one of these glob patterns. May be repeated."`
+	ExcludeGroups     []string `help:"Never export resources whose API group matches one of these glob patterns, even if they also match --include-groups. May be repeated."`
+	IncludeGVKs       []string `help:"Only export resources matching one of these \"Kind.group\" glob patterns. May be repeated."`
+	ExcludeGVKs       []string `help:"Never export resources matching one of these \"Kind.group\" glob patterns, even if they also match --include-gvks. May be repeated."`
+	IncludeNamespaces []string `help:"Only export namespaced resources in one of these namespaces. May be repeated."`
+	LabelSelector     string   `help:"Only export resources matching this label selector."`
+
+	Pause bool `default:"true" negatable:"" help:"Pause managed resources, via the crossplane.io/paused annotation, before exporting them. Use --no-pause to export a live control plane without pausing it; providers may modify state mid-export, producing an inconsistent snapshot."`
+}
+
+// Run executes the export command.
+func (c *ExportCmd) Run() error {
+	cfg, err := kube.GetKubeConfig(c.Kubeconfig)
+	if err != nil {
+		return errors.Wrap(err, "cannot get kubeconfig")
+	}
+
+	crdClient, err := apiextensionsclientset.NewForConfig(cfg)
+	if err != nil {
+		return errors.Wrap(err, "cannot create apiextensions client")
+	}
+	dynamicClient, err := dynamic.NewForConfig(cfg)
+	if err != nil {
+		return errors.Wrap(err, "cannot create dynamic client")
+	}
+	clientset, err := kubernetes.NewForConfig(cfg)
+	if err != nil {
+		return errors.Wrap(err, "cannot create kubernetes client")
+	}
+	discoveryClient := clientset.Discovery()
+	mapper := restmapper.NewDeferredDiscoveryRESTMapper(memory.NewMemCacheClient(discoveryClient))
+
+	if !c.Pause {
+		pterm.Warning.Printfln("Exporting without pausing managed resources; providers may modify state mid-export, producing an inconsistent snapshot.")
+	}
+
+	opts := exporter.Options{
+		OutputArchive:     c.Output,
+		IncludeGroups:     c.IncludeGroups,
+		ExcludeGroups:     c.ExcludeGroups,
+		IncludeGVKs:       c.IncludeGVKs,
+		ExcludeGVKs:       c.ExcludeGVKs,
+		IncludeNamespaces: c.IncludeNamespaces,
+		LabelSelector:     c.LabelSelector,
+		PauseBeforeExport: c.Pause,
+	}
+
+	e := exporter.NewControlPlaneStateExporter(crdClient, dynamicClient, discoveryClient, clientset.AppsV1(), mapper, opts)
+	return e.Export(context.Background())
+}
@@ -0,0 +1,77 @@
+// Copyright 2024 Upbound Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sos
+
+import (
+	"context"
+
+	"github.com/crossplane/crossplane-runtime/pkg/errors"
+	apiextensionsclientset "k8s.io/apiextensions-apiserver/pkg/client/clientset/clientset"
+	"k8s.io/client-go/discovery/cached/memory"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/restmapper"
+
+	"github.com/upbound/up/internal/kube"
+	"github.com/upbound/up/internal/sos/importer"
+)
+
+// ImportCmd imports a previously exported control plane state.
+type ImportCmd struct {
+	Kubeconfig string `type:"path" help:"Path to the kubeconfig of the control plane to import into. Defaults to the current kubeconfig context."`
+	Input      string `arg:"" help:"Path to the archive produced by 'up sos export'."`
+
+	WorkDir string `type:"path" help:"Directory the archive is unpacked into and the checkpoint file is kept in. Defaults to a stable directory derived from the input archive, so a re-run after a failure resumes instead of starting over."`
+
+	Unpause bool `default:"true" negatable:"" help:"Remove the crossplane.io/paused annotation from resources the export paused, once the import completes and all packages are Healthy. Use --no-unpause to leave them paused."`
+
+	AgeIdentity []string `help:"Age private key used to decrypt fields the export encrypted. Required if the archive was exported with encryption enabled. May be repeated."`
+}
+
+// Run executes the import command.
+func (c *ImportCmd) Run() error {
+	cfg, err := kube.GetKubeConfig(c.Kubeconfig)
+	if err != nil {
+		return errors.Wrap(err, "cannot get kubeconfig")
+	}
+
+	crdClient, err := apiextensionsclientset.NewForConfig(cfg)
+	if err != nil {
+		return errors.Wrap(err, "cannot create apiextensions client")
+	}
+	dynamicClient, err := dynamic.NewForConfig(cfg)
+	if err != nil {
+		return errors.Wrap(err, "cannot create dynamic client")
+	}
+	clientset, err := kubernetes.NewForConfig(cfg)
+	if err != nil {
+		return errors.Wrap(err, "cannot create kubernetes client")
+	}
+	discoveryClient := clientset.Discovery()
+	mapper := restmapper.NewDeferredDiscoveryRESTMapper(memory.NewMemCacheClient(discoveryClient))
+
+	opts := importer.Options{
+		InputArchive:       c.Input,
+		WorkDir:            c.WorkDir,
+		UnpauseAfterImport: c.Unpause,
+		AgeIdentities:      c.AgeIdentity,
+	}
+
+	imp, err := importer.NewControlPlaneStateImporter(crdClient, dynamicClient, discoveryClient, mapper, opts)
+	if err != nil {
+		return errors.Wrap(err, "cannot construct importer")
+	}
+	return imp.Import(context.Background())
+}
@@ -0,0 +1,23 @@
+// Copyright 2024 Upbound Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package sos contains commands for exporting and importing the full state
+// of a Crossplane control plane, e.g. for disaster recovery or migration.
+package sos
+
+// Cmd contains commands for exporting and importing control plane state.
+type Cmd struct {
+	Export ExportCmd `cmd:"" help:"Export the full state of a control plane."`
+	Import ImportCmd `cmd:"" help:"Import a previously exported control plane state."`
+}
@@ -0,0 +1,185 @@
+// Copyright 2024 Upbound Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exporter
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+
+	"github.com/crossplane/crossplane-runtime/pkg/errors"
+	"github.com/mholt/archiver/v4"
+	"github.com/spf13/afero"
+)
+
+const (
+	// defaultArchivePartSize is the default size, in bytes, of each part
+	// used when streaming an archive to S3Sink.
+	defaultArchivePartSize = 64 * 1024 * 1024 // 64MiB
+
+	// defaultArchiveConcurrency is the default number of parts uploaded
+	// concurrently when streaming an archive to S3Sink.
+	defaultArchiveConcurrency = 4
+)
+
+// ArchiveSink persists the exported state rooted at dir to a destination of
+// its choosing, e.g. a local file, or an upload to object storage.
+type ArchiveSink interface {
+	// Write persists the contents of dir, as seen through fs, to the sink.
+	Write(ctx context.Context, fs afero.Afero, dir string) error
+}
+
+// NewArchiveSink returns the ArchiveSink that Options.OutputArchive selects.
+// The destination's URL scheme picks the sink: no scheme or "file://" for a
+// local tar.gz (TarGzFileSink), "zip://" for a local zip (ZipFileSink),
+// "dir://" to copy the tree as-is with no archival (DirectorySink),
+// "s3://bucket/key" for S3Sink, and "gs://bucket/object" for GCSSink.
+func NewArchiveSink(opts Options) (ArchiveSink, error) {
+	scheme, rest := splitArchiveScheme(opts.OutputArchive)
+
+	switch scheme {
+	case "", "file":
+		return &TarGzFileSink{Path: rest}, nil
+	case "zip":
+		return &ZipFileSink{Path: rest}, nil
+	case "dir":
+		return &DirectorySink{Path: rest}, nil
+	case "s3":
+		return NewS3Sink(rest, opts)
+	case "gs":
+		return NewGCSSink(rest, opts)
+	default:
+		return nil, errors.Errorf("unsupported output archive scheme %q", scheme)
+	}
+}
+
+// splitArchiveScheme splits a destination of the form "scheme://rest" into
+// its scheme and the remainder. A destination with no "://" is treated as
+// having no scheme, i.e. a plain local file path.
+func splitArchiveScheme(dest string) (scheme, rest string) {
+	const sep = "://"
+	for i := 0; i+len(sep) <= len(dest); i++ {
+		if dest[i:i+len(sep)] == sep {
+			return dest[:i], dest[i+len(sep):]
+		}
+	}
+	return "", dest
+}
+
+// TarGzFileSink archives the exported state as a gzip-compressed tarball
+// written to a local file. This is the sink the exporter has always used.
+type TarGzFileSink struct {
+	// Path is the local file path the archive is written to.
+	Path string
+}
+
+// Write implements ArchiveSink.
+func (s *TarGzFileSink) Write(ctx context.Context, fs afero.Afero, dir string) error {
+	files, err := archiver.FilesFromDisk(nil, map[string]string{
+		dir + "/": "",
+	})
+	if err != nil {
+		return err
+	}
+
+	out, err := fs.Create(s.Path)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = out.Close()
+	}()
+
+	if err := fs.Chmod(s.Path, 0600); err != nil {
+		return err
+	}
+
+	format := archiver.CompressedArchive{
+		Compression: archiver.Gz{},
+		Archival:    archiver.Tar{},
+	}
+
+	return format.Archive(ctx, out, files)
+}
+
+// ZipFileSink archives the exported state as a zip file written to a local
+// file.
+type ZipFileSink struct {
+	// Path is the local file path the archive is written to.
+	Path string
+}
+
+// Write implements ArchiveSink.
+func (s *ZipFileSink) Write(ctx context.Context, fs afero.Afero, dir string) error {
+	files, err := archiver.FilesFromDisk(nil, map[string]string{
+		dir + "/": "",
+	})
+	if err != nil {
+		return err
+	}
+
+	out, err := fs.Create(s.Path)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = out.Close()
+	}()
+
+	if err := fs.Chmod(s.Path, 0600); err != nil {
+		return err
+	}
+
+	format := archiver.Zip{}
+
+	return format.Archive(ctx, out, files)
+}
+
+// DirectorySink leaves the exported state as a plain directory tree, with
+// no archival step. It's useful for GitOps or diff-based workflows that
+// want to inspect or commit the exported manifests directly.
+type DirectorySink struct {
+	// Path is the local directory the exported state is copied to.
+	Path string
+}
+
+// Write implements ArchiveSink.
+func (s *DirectorySink) Write(_ context.Context, fs afero.Afero, dir string) error {
+	if err := fs.MkdirAll(s.Path, 0700); err != nil {
+		return errors.Wrapf(err, "cannot create output directory %q", s.Path)
+	}
+
+	return fs.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(s.Path, rel)
+
+		if info.IsDir() {
+			return fs.MkdirAll(target, 0700)
+		}
+
+		b, err := fs.ReadFile(path)
+		if err != nil {
+			return errors.Wrapf(err, "cannot read %q", path)
+		}
+		return fs.WriteFile(target, b, 0600)
+	})
+}
@@ -0,0 +1,199 @@
+// Copyright 2024 Upbound Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exporter
+
+import (
+	"context"
+	"io"
+	"strings"
+
+	"cloud.google.com/go/storage"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/feature/s3/manager"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	s3types "github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/crossplane/crossplane-runtime/pkg/errors"
+	"github.com/mholt/archiver/v4"
+	"github.com/spf13/afero"
+	"golang.org/x/sync/errgroup"
+)
+
+// S3Sink streams the exported state as a gzip-compressed tarball directly
+// to an object in S3, using a multipart upload so very large control-plane
+// snapshots don't need to be buffered in memory.
+type S3Sink struct {
+	// Bucket is the destination S3 bucket.
+	Bucket string
+	// Key is the destination object key within Bucket.
+	Key string
+	// PartSize is the size, in bytes, of each part of the multipart
+	// upload.
+	PartSize int64
+	// Concurrency is the number of parts uploaded concurrently.
+	Concurrency int
+	// KMSKeyID, if set, requests SSE-KMS encryption of the uploaded
+	// object with the given key.
+	KMSKeyID string
+
+	client *s3.Client
+}
+
+// NewS3Sink returns an S3Sink for "bucket/key", configured from opts and
+// using the default AWS credential chain.
+func NewS3Sink(dest string, opts Options) (*S3Sink, error) {
+	bucket, key, err := splitBucketAndKey(dest)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg, err := config.LoadDefaultConfig(context.Background())
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot load AWS config")
+	}
+
+	partSize := opts.ArchivePartSize
+	if partSize <= 0 {
+		partSize = defaultArchivePartSize
+	}
+	concurrency := opts.ArchiveConcurrency
+	if concurrency < 1 {
+		concurrency = defaultArchiveConcurrency
+	}
+
+	return &S3Sink{
+		Bucket:      bucket,
+		Key:         key,
+		PartSize:    partSize,
+		Concurrency: concurrency,
+		KMSKeyID:    opts.ArchiveKMSKeyID,
+		client:      s3.NewFromConfig(cfg),
+	}, nil
+}
+
+// Write implements ArchiveSink. It archives dir and streams it to S3
+// through a pipe, so the upload starts before the archive is complete.
+func (s *S3Sink) Write(ctx context.Context, fs afero.Afero, dir string) error {
+	files, err := archiver.FilesFromDisk(nil, map[string]string{
+		dir + "/": "",
+	})
+	if err != nil {
+		return err
+	}
+
+	pr, pw := io.Pipe()
+	format := archiver.CompressedArchive{
+		Compression: archiver.Gz{},
+		Archival:    archiver.Tar{},
+	}
+
+	g, gctx := errgroup.WithContext(ctx)
+	g.Go(func() error {
+		err := format.Archive(gctx, pw, files)
+		_ = pw.CloseWithError(err)
+		return err
+	})
+
+	input := &s3.PutObjectInput{
+		Bucket: aws.String(s.Bucket),
+		Key:    aws.String(s.Key),
+		Body:   pr,
+	}
+	if s.KMSKeyID != "" {
+		input.ServerSideEncryption = s3types.ServerSideEncryptionAwsKms
+		input.SSEKMSKeyId = aws.String(s.KMSKeyID)
+	}
+
+	g.Go(func() error {
+		uploader := manager.NewUploader(s.client, func(u *manager.Uploader) {
+			u.PartSize = s.PartSize
+			u.Concurrency = s.Concurrency
+		})
+		_, err := uploader.Upload(gctx, input)
+		return err
+	})
+
+	return g.Wait()
+}
+
+// GCSSink streams the exported state as a gzip-compressed tarball directly
+// to an object in Google Cloud Storage.
+type GCSSink struct {
+	// Bucket is the destination GCS bucket.
+	Bucket string
+	// Object is the destination object name within Bucket.
+	Object string
+	// KMSKeyName, if set, requests encryption of the uploaded object with
+	// the given Cloud KMS key.
+	KMSKeyName string
+
+	client *storage.Client
+}
+
+// NewGCSSink returns a GCSSink for "bucket/object", configured from opts
+// and using application default credentials.
+func NewGCSSink(dest string, opts Options) (*GCSSink, error) {
+	bucket, object, err := splitBucketAndKey(dest)
+	if err != nil {
+		return nil, err
+	}
+
+	client, err := storage.NewClient(context.Background())
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot create GCS client")
+	}
+
+	return &GCSSink{
+		Bucket:     bucket,
+		Object:     object,
+		KMSKeyName: opts.ArchiveKMSKeyID,
+		client:     client,
+	}, nil
+}
+
+// Write implements ArchiveSink.
+func (s *GCSSink) Write(ctx context.Context, fs afero.Afero, dir string) error {
+	files, err := archiver.FilesFromDisk(nil, map[string]string{
+		dir + "/": "",
+	})
+	if err != nil {
+		return err
+	}
+
+	w := s.client.Bucket(s.Bucket).Object(s.Object).NewWriter(ctx)
+	if s.KMSKeyName != "" {
+		w.KMSKeyName = s.KMSKeyName
+	}
+
+	format := archiver.CompressedArchive{
+		Compression: archiver.Gz{},
+		Archival:    archiver.Tar{},
+	}
+	if err := format.Archive(ctx, w, files); err != nil {
+		_ = w.Close()
+		return err
+	}
+	return w.Close()
+}
+
+// splitBucketAndKey splits a "bucket/key" destination, as found after the
+// "s3://" or "gs://" scheme is stripped, into its bucket and key.
+func splitBucketAndKey(dest string) (bucket, key string, err error) {
+	parts := strings.SplitN(dest, "/", 2)
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return "", "", errors.Errorf("expected <bucket>/<key>, got %q", dest)
+	}
+	return parts[0], parts[1], nil
+}
@@ -0,0 +1,222 @@
+// Copyright 2024 Upbound Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exporter
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"strings"
+
+	"filippo.io/age"
+	"github.com/crossplane/crossplane-runtime/pkg/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	"github.com/upbound/up/internal/migration/meta/v1alpha1"
+)
+
+// defaultSensitiveKinds are encrypted even when
+// EncryptionOptions.SensitiveKinds is unset, since their data/stringData
+// fields almost always hold credentials.
+var defaultSensitiveKinds = []string{"Secret"}
+
+// EncryptionOptions controls field-level encryption of sensitive fields in
+// exported resources, so an SOS report archive is safe to share without
+// leaking Secret bodies or provider credentials in plaintext.
+type EncryptionOptions struct {
+	// Enabled turns on encryption of sensitive fields before they're
+	// persisted.
+	Enabled bool
+
+	// SensitiveKinds lists the `kind`s whose sensitive fields are
+	// encrypted. Defaults to []string{"Secret"}.
+	SensitiveKinds []string
+
+	// FieldPaths lists additional dotted field paths, within any
+	// sensitive-kind object, whose values are encrypted in addition to a
+	// Secret's data/stringData, e.g.
+	// "spec.forProvider.credentialsSecretRef".
+	FieldPaths []string
+
+	// AgeRecipients are the age recipient public keys sensitive fields
+	// are encrypted to.
+	AgeRecipients []string
+}
+
+func (o EncryptionOptions) sensitiveKinds() []string {
+	if len(o.SensitiveKinds) == 0 {
+		return defaultSensitiveKinds
+	}
+	return o.SensitiveKinds
+}
+
+func (o EncryptionOptions) isSensitive(kind string) bool {
+	for _, k := range o.sensitiveKinds() {
+		if k == kind {
+			return true
+		}
+	}
+	return false
+}
+
+// Persister is implemented by the exporter's FileSystemPersister. It
+// persists a single resource of gvr to the export's working directory.
+type Persister interface {
+	Persist(ctx context.Context, gvr schema.GroupVersionResource, obj *unstructured.Unstructured) error
+}
+
+// EncryptingPersister wraps a Persister, encrypting the sensitive fields of
+// any object whose kind is in EncryptionOptions.SensitiveKinds before
+// handing it off, so the wrapped Persister never sees plaintext
+// credentials. Objects of other kinds pass through untouched, which keeps
+// the archive diff-friendly.
+type EncryptingPersister struct {
+	next     Persister
+	opts     EncryptionOptions
+	typeMeta *v1alpha1.TypeMeta
+
+	recipients []age.Recipient
+}
+
+// NewEncryptingPersister returns a Persister that encrypts sensitive fields
+// before delegating to next. The configured age recipients, and the
+// per-object field paths that were encrypted, are recorded on typeMeta so
+// a future importer can decrypt symmetrically.
+func NewEncryptingPersister(next Persister, opts EncryptionOptions, typeMeta *v1alpha1.TypeMeta) (*EncryptingPersister, error) {
+	recipients := make([]age.Recipient, 0, len(opts.AgeRecipients))
+	for _, r := range opts.AgeRecipients {
+		recipient, err := age.ParseX25519Recipient(r)
+		if err != nil {
+			return nil, errors.Wrapf(err, "cannot parse age recipient %q", r)
+		}
+		recipients = append(recipients, recipient)
+	}
+
+	typeMeta.EncryptionRecipients = opts.AgeRecipients
+
+	return &EncryptingPersister{
+		next:       next,
+		opts:       opts,
+		typeMeta:   typeMeta,
+		recipients: recipients,
+	}, nil
+}
+
+// Persist implements Persister.
+func (p *EncryptingPersister) Persist(ctx context.Context, gvr schema.GroupVersionResource, obj *unstructured.Unstructured) error {
+	if !p.opts.isSensitive(obj.GetKind()) {
+		return p.next.Persist(ctx, gvr, obj)
+	}
+
+	var encryptedPaths []string
+
+	if err := p.encryptStringMapField(obj, "data", &encryptedPaths); err != nil {
+		return err
+	}
+	if err := p.encryptStringMapField(obj, "stringData", &encryptedPaths); err != nil {
+		return err
+	}
+	for _, path := range p.opts.FieldPaths {
+		if err := p.encryptFieldPath(obj, path, &encryptedPaths); err != nil {
+			return err
+		}
+	}
+
+	if len(encryptedPaths) > 0 {
+		key := obj.GetName()
+		if ns := obj.GetNamespace(); ns != "" {
+			key = ns + "/" + key
+		}
+		if p.typeMeta.EncryptedFieldPaths == nil {
+			p.typeMeta.EncryptedFieldPaths = map[string][]string{}
+		}
+		p.typeMeta.EncryptedFieldPaths[key] = encryptedPaths
+	}
+
+	return p.next.Persist(ctx, gvr, obj)
+}
+
+func (p *EncryptingPersister) encryptStringMapField(obj *unstructured.Unstructured, field string, encryptedPaths *[]string) error {
+	m, found, err := unstructured.NestedStringMap(obj.Object, field)
+	if err != nil || !found {
+		return err
+	}
+	for k, v := range m {
+		enc, err := p.encrypt([]byte(v))
+		if err != nil {
+			return errors.Wrapf(err, "cannot encrypt %s.%s", field, k)
+		}
+		m[k] = enc
+		*encryptedPaths = append(*encryptedPaths, field+"."+k)
+	}
+	return unstructured.SetNestedStringMap(obj.Object, m, field)
+}
+
+// encryptFieldPath encrypts the value at path within obj. The value may be
+// a single string, e.g. a credential, or a map of strings, e.g. a
+// SecretKeySelector-shaped reference such as
+// spec.forProvider.credentialsSecretRef. Any other kind of value at path,
+// or non-string entry within such a map, is left untouched rather than
+// erroring: we have no way to tell whether it's safe to treat as opaque
+// sensitive data.
+func (p *EncryptingPersister) encryptFieldPath(obj *unstructured.Unstructured, path string, encryptedPaths *[]string) error {
+	fields := strings.Split(path, ".")
+	v, found, err := unstructured.NestedFieldNoCopy(obj.Object, fields...)
+	if err != nil || !found {
+		return err
+	}
+
+	switch val := v.(type) {
+	case string:
+		enc, err := p.encrypt([]byte(val))
+		if err != nil {
+			return errors.Wrapf(err, "cannot encrypt %q", path)
+		}
+		if err := unstructured.SetNestedField(obj.Object, enc, fields...); err != nil {
+			return err
+		}
+		*encryptedPaths = append(*encryptedPaths, path)
+	case map[string]interface{}:
+		for k, sub := range val {
+			s, ok := sub.(string)
+			if !ok {
+				continue
+			}
+			enc, err := p.encrypt([]byte(s))
+			if err != nil {
+				return errors.Wrapf(err, "cannot encrypt %s.%s", path, k)
+			}
+			val[k] = enc
+			*encryptedPaths = append(*encryptedPaths, path+"."+k)
+		}
+	}
+	return nil
+}
+
+func (p *EncryptingPersister) encrypt(plaintext []byte) (string, error) {
+	var buf bytes.Buffer
+	w, err := age.Encrypt(&buf, p.recipients...)
+	if err != nil {
+		return "", err
+	}
+	if _, err := w.Write(plaintext); err != nil {
+		return "", err
+	}
+	if err := w.Close(); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(buf.Bytes()), nil
+}
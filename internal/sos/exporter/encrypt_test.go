@@ -0,0 +1,79 @@
+// Copyright 2024 Upbound Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exporter
+
+import (
+	"testing"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	"github.com/upbound/up/internal/migration/meta/v1alpha1"
+)
+
+func TestEncryptFieldPathDoesNotErrorOnNonLeafValues(t *testing.T) {
+	recipient := "age1ql3z7hjy54pw3hyww5ayyfg7zqgvc7w3j2elw8zmrj2kg5sfn9aqmcac8p"
+
+	cases := map[string]struct {
+		path   string
+		obj    map[string]interface{}
+		wantOK bool // whether the path's values should end up encrypted
+	}{
+		"LeafString": {
+			path:   "spec.forProvider.apiKey",
+			obj:    map[string]interface{}{"spec": map[string]interface{}{"forProvider": map[string]interface{}{"apiKey": "super-secret"}}},
+			wantOK: true,
+		},
+		"ObjectOfStrings": {
+			// The request's own example: a SecretKeySelector-shaped
+			// reference, not a leaf string. This used to make
+			// unstructured.NestedString return an error that aborted the
+			// whole export.
+			path: "spec.forProvider.credentialsSecretRef",
+			obj: map[string]interface{}{"spec": map[string]interface{}{"forProvider": map[string]interface{}{
+				"credentialsSecretRef": map[string]interface{}{"name": "creds", "namespace": "default", "key": "token"},
+			}}},
+			wantOK: true,
+		},
+		"NonStringLeaf": {
+			path:   "spec.forProvider.replicas",
+			obj:    map[string]interface{}{"spec": map[string]interface{}{"forProvider": map[string]interface{}{"replicas": int64(3)}}},
+			wantOK: false,
+		},
+		"MissingPath": {
+			path:   "spec.forProvider.missing",
+			obj:    map[string]interface{}{"spec": map[string]interface{}{"forProvider": map[string]interface{}{}}},
+			wantOK: false,
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			p, err := NewEncryptingPersister(nil, EncryptionOptions{AgeRecipients: []string{recipient}}, &v1alpha1.TypeMeta{})
+			if err != nil {
+				t.Fatalf("NewEncryptingPersister() returned an error: %v", err)
+			}
+
+			obj := &unstructured.Unstructured{Object: tc.obj}
+			var encryptedPaths []string
+			if err := p.encryptFieldPath(obj, tc.path, &encryptedPaths); err != nil {
+				t.Fatalf("encryptFieldPath(%q) returned an error: %v", tc.path, err)
+			}
+
+			if got := len(encryptedPaths) > 0; got != tc.wantOK {
+				t.Errorf("encryptFieldPath(%q) encrypted paths = %v, want non-empty: %t", tc.path, encryptedPaths, tc.wantOK)
+			}
+		})
+	}
+}
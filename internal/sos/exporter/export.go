@@ -17,12 +17,15 @@ package exporter
 import (
 	"context"
 	"fmt"
+	"path"
 	"strings"
+	"sync"
+	"sync/atomic"
 
 	"github.com/crossplane/crossplane-runtime/pkg/errors"
-	"github.com/mholt/archiver/v4"
 	"github.com/pterm/pterm"
 	"github.com/spf13/afero"
+	"golang.org/x/sync/errgroup"
 	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
 	apiextensionsclientset "k8s.io/apiextensions-apiserver/pkg/client/clientset/clientset"
 	"k8s.io/apimachinery/pkg/api/meta"
@@ -31,15 +34,122 @@ import (
 	"k8s.io/client-go/discovery"
 	"k8s.io/client-go/dynamic"
 	appsv1 "k8s.io/client-go/kubernetes/typed/apps/v1"
+	"k8s.io/client-go/util/flowcontrol"
 
 	"github.com/upbound/up/internal/migration/meta/v1alpha1"
 	"github.com/upbound/up/internal/upterm"
 )
 
+const (
+	// defaultPageSize is the default number of resources fetched per list
+	// call while exporting.
+	defaultPageSize = 500
+	// defaultConcurrency is the default number of workers used to export
+	// resource types concurrently.
+	defaultConcurrency = 4
+	// defaultQPS is the default rate, per second, at which export workers
+	// may start exporting a resource type.
+	defaultQPS = 20
+	// defaultBurst is the default burst size of the rate limiter export
+	// workers wait on.
+	defaultBurst = 30
+)
+
 // Options for the exporter.
 type Options struct {
 	// OutputArchive is the path to the archive file to be created.
 	OutputArchive string
+
+	// Concurrency is the number of workers used to export resources of
+	// different types concurrently. Defaults to defaultConcurrency.
+	Concurrency int
+
+	// PageSize is the number of resources fetched per list call when
+	// exporting resources of a given type. Defaults to defaultPageSize.
+	PageSize int64
+
+	// QPS is the rate, per second, of a token bucket rate limiter shared by
+	// the export workers, which each worker waits on once before it starts
+	// exporting a resource type. The list and patch calls made while
+	// pausing a type's resources are not counted against it. Defaults to
+	// defaultQPS.
+	QPS float32
+
+	// Burst is the burst size of the rate limiter QPS configures. Defaults
+	// to defaultBurst.
+	Burst int
+
+	// IncludeGroups is a list of glob patterns matched against a type's API
+	// group. If non-empty, only types whose group matches one of these
+	// patterns are considered for export, before ExcludeGroups is applied.
+	IncludeGroups []string
+
+	// ExcludeGroups is a list of glob patterns matched against a type's API
+	// group. Types whose group matches any of these patterns are never
+	// exported, even if they also match IncludeGroups.
+	ExcludeGroups []string
+
+	// IncludeGVKs is a list of glob patterns of the form "Kind.group"
+	// matched against a type's GroupKind. If non-empty, only types that
+	// match one of these patterns are considered for export, before
+	// ExcludeGVKs is applied.
+	IncludeGVKs []string
+
+	// ExcludeGVKs is a list of glob patterns of the form "Kind.group"
+	// matched against a type's GroupKind. Types that match any of these
+	// patterns are never exported, even if they also match IncludeGVKs.
+	ExcludeGVKs []string
+
+	// IncludeNamespaces restricts exported namespaced resources to the
+	// given namespaces. If empty, resources in all namespaces are
+	// exported.
+	IncludeNamespaces []string
+
+	// LabelSelector further restricts exported resources to those that
+	// match the given label selector. It is passed through to the dynamic
+	// List call made for each exported type.
+	LabelSelector string
+
+	// PauseBeforeExport patches the crossplane.io/paused annotation onto
+	// every exported managed resource (every resource of a type whose CRD
+	// carries the "managed" category) before it's read, so that reconciliation
+	// can't cause the control plane to drift mid-export. Resources this
+	// patches are recorded in the export metadata so a later Import with
+	// UnpauseAfterImport can remove the annotation again.
+	PauseBeforeExport bool
+
+	// ArchivePartSize is the size, in bytes, of each part used when
+	// streaming the archive to S3Sink via multipart upload. GCSSink does
+	// not use it. Defaults to defaultArchivePartSize.
+	ArchivePartSize int64
+
+	// ArchiveConcurrency is the number of parts uploaded concurrently when
+	// streaming the archive to S3Sink. GCSSink does not use it. Defaults to
+	// defaultArchiveConcurrency.
+	ArchiveConcurrency int
+
+	// ArchiveKMSKeyID, if set, is passed to the object storage sink
+	// (S3Sink, GCSSink) to request server-side encryption of the uploaded
+	// archive with the given KMS key.
+	ArchiveKMSKeyID string
+
+	// Encryption configures field-level encryption of sensitive fields
+	// (e.g. Secret data) so the archive is safe to share even though it's
+	// not otherwise encrypted as a whole.
+	Encryption EncryptionOptions
+
+	// EmitKustomize additionally writes, under a kustomize/ directory
+	// alongside the raw resources/ export, a copy of every exported
+	// manifest with server-populated fields stripped, plus a
+	// kustomization.yaml per resource group and one composing them at the
+	// kustomize/ root. This makes the export additionally renderable with
+	// `kustomize build`, without altering the raw export itself.
+	EmitKustomize bool
+
+	// KustomizeStripFields overrides the dotted field paths stripped from
+	// every manifest when EmitKustomize is enabled. Defaults to
+	// defaultStripFields.
+	KustomizeStripFields []string
 }
 
 // ControlPlaneStateExporter exports the state of a Crossplane control plane.
@@ -90,55 +200,147 @@ func (e *ControlPlaneStateExporter) Export(ctx context.Context) error { // nolin
 	}
 	exportList := make([]apiextensionsv1.CustomResourceDefinition, 0, len(crdList))
 	for _, crd := range crdList {
-		// We only want to export the following types:
-		// - Crossplane Core CRDs - Has suffix ".crossplane.io".
-		// - CRDs owned by Crossplane packages - Has owner reference to a Crossplane package.
-		// - CRDs owned by a CompositeResourceDefinition - Has owner reference to a CompositeResourceDefinition.
+		// Ignore CRDs that aren't Crossplane-managed or that the caller
+		// excluded via Options.
 		if !e.shouldExport(crd) {
-			// Ignore CRDs that we don't want to export.
 			continue
 		}
 		exportList = append(exportList, crd)
 	}
 	s.Success(scanMsg + fmt.Sprintf("%d types found! 👀", len(exportList)))
 
-	// Export Crossplane resources.
-	crCounts := make(map[string]int, len(exportList))
+	// Export Crossplane resources. Resource types are fanned out across a
+	// worker pool so that, e.g., fetching thousands of managed resources of
+	// one type doesn't block exporting a handful of resources of another.
+	// A shared rate limiter protects the API server from a
+	// concurrency-sized burst of requests, and workers are cancelled as
+	// soon as any one of them fails.
 	exportCRsMsg := "Exporting sos report resources... "
 	s, _ = upterm.CheckmarkSuccessSpinner.Start(exportCRsMsg + fmt.Sprintf("0 / %d", len(exportList)))
-	for _, crd := range exportList {
-		gvr, err := e.customResourceGVR(crd)
-		if err != nil {
-			s.Fail(exportCRsMsg + "Failed!")
-			return errors.Wrapf(err, "cannot get GVR for %q", crd.GetName())
-		}
 
-		s.UpdateText(exportCRsMsg + fmt.Sprintf("Analyse %s...", gvr.GroupResource()))
+	concurrency := e.options.Concurrency
+	if concurrency < 1 {
+		concurrency = defaultConcurrency
+	}
+	qps := e.options.QPS
+	if qps <= 0 {
+		qps = defaultQPS
+	}
+	burst := e.options.Burst
+	if burst < 1 {
+		burst = defaultBurst
+	}
+	limiter := flowcontrol.NewTokenBucketRateLimiter(qps, burst)
+
+	var (
+		mu          sync.Mutex
+		crCounts    = make(map[string]int, len(exportList))
+		pausedByGVR = make(map[string][]string, len(exportList))
+		done        int32
+	)
+
+	progress := make(chan struct{}, concurrency)
+	progressDone := make(chan struct{})
+	go func() {
+		defer close(progressDone)
+		for range progress {
+			n := atomic.LoadInt32(&done)
+			s.UpdateText(exportCRsMsg + fmt.Sprintf("%d / %d", n, len(exportList)))
+		}
+	}()
 
-		sub := false
-		for _, vr := range crd.Spec.Versions {
-			if vr.Storage && vr.Subresources != nil && vr.Subresources.Status != nil {
-				// This CRD has a status subresource. We store this as a metadata per type and use
-				// it during import to determine if we should apply the status subresource.
-				sub = true
-				break
+	g, gctx := errgroup.WithContext(ctx)
+	work := make(chan apiextensionsv1.CustomResourceDefinition)
+	for i := 0; i < concurrency; i++ {
+		g.Go(func() error {
+			for crd := range work {
+				gvr, err := e.customResourceGVR(crd)
+				if err != nil {
+					return errors.Wrapf(err, "cannot get GVR for %q", crd.GetName())
+				}
+
+				if err := limiter.Wait(gctx); err != nil {
+					return errors.Wrapf(err, "rate limiter wait failed for %q", crd.GetName())
+				}
+
+				// Only managed resources, i.e. types whose CRD carries the
+				// "managed" category, are paused.
+				var paused []string
+				for _, c := range crd.Spec.Names.Categories {
+					if c == "managed" {
+						paused, err = e.pauseResources(gctx, gvr)
+						break
+					}
+				}
+				if err != nil {
+					return errors.Wrapf(err, "cannot pause resources for %q", crd.GetName())
+				}
+				if len(paused) > 0 {
+					mu.Lock()
+					pausedByGVR[gvr.GroupResource().String()] = paused
+					mu.Unlock()
+				}
+
+				sub := false
+				for _, vr := range crd.Spec.Versions {
+					if vr.Storage && vr.Subresources != nil && vr.Subresources.Status != nil {
+						// This CRD has a status subresource. We store this as a metadata per type and use
+						// it during import to determine if we should apply the status subresource.
+						sub = true
+						break
+					}
+				}
+				typeMeta := &v1alpha1.TypeMeta{
+					Categories:            crd.Spec.Names.Categories,
+					WithStatusSubresource: sub,
+				}
+
+				var persister Persister = NewFileSystemPersister(fs, tmpDir, typeMeta)
+				if e.options.Encryption.Enabled {
+					persister, err = NewEncryptingPersister(persister, e.options.Encryption, typeMeta)
+					if err != nil {
+						return errors.Wrapf(err, "cannot set up encryption for %q", crd.GetName())
+					}
+				}
+
+				exporter := NewUnstructuredExporter(
+					NewUnstructuredFetcher(e.dynamicClient, e.options),
+					persister)
+
+				// ExportResource will fetch all resources of the given GVR and store them in the
+				// well-known directory structure.
+				count, err := exporter.ExportResources(gctx, gvr)
+				if err != nil {
+					return errors.Wrapf(err, "cannot export resources for %q", crd.GetName())
+				}
+
+				mu.Lock()
+				crCounts[gvr.GroupResource().String()] = count
+				mu.Unlock()
+
+				atomic.AddInt32(&done, 1)
+				progress <- struct{}{}
 			}
+			return nil
+		})
+	}
+
+dispatch:
+	for _, crd := range exportList {
+		select {
+		case work <- crd:
+		case <-gctx.Done():
+			break dispatch
 		}
-		exporter := NewUnstructuredExporter(
-			NewUnstructuredFetcher(e.dynamicClient, e.options),
-			NewFileSystemPersister(fs, tmpDir, &v1alpha1.TypeMeta{
-				Categories:            crd.Spec.Names.Categories,
-				WithStatusSubresource: sub,
-			}))
-
-		// ExportResource will fetch all resources of the given GVR and store them in the
-		// well-known directory structure.
-		count, err := exporter.ExportResources(ctx, gvr)
-		if err != nil {
-			s.Fail(exportCRsMsg + "Failed!")
-			return errors.Wrapf(err, "cannot export resources for %q", crd.GetName())
-		}
-		crCounts[gvr.GroupResource().String()] = count
+	}
+	close(work)
+
+	err = g.Wait()
+	close(progress)
+	<-progressDone
+	if err != nil {
+		s.Fail(exportCRsMsg + "Failed!")
+		return err
 	}
 
 	total := 0
@@ -152,14 +354,31 @@ func (e *ControlPlaneStateExporter) Export(ctx context.Context) error { // nolin
 	// This metadata file is used during import to determine if the import is compatible with the
 	// current Crossplane version and feature flags and also enables manual inspection the exported state.
 	me := NewPersistentMetadataExporter(e.appsClient, e.dynamicClient, fs, tmpDir)
-	if err = me.ExportMetadata(ctx, e.options, crCounts); err != nil {
+	if err = me.ExportMetadata(ctx, e.options, crCounts, pausedByGVR); err != nil {
 		return errors.Wrap(err, "cannot write export metadata")
 	}
 
-	// Archive the sos report state.
+	if e.options.EmitKustomize {
+		kustomizeMsg := "Generating kustomize overlay... "
+		s, _ = upterm.CheckmarkSuccessSpinner.Start(kustomizeMsg)
+		if err = e.emitKustomizeOverlay(fs, tmpDir); err != nil {
+			s.Fail(kustomizeMsg + "Failed!")
+			return errors.Wrap(err, "cannot generate kustomize overlay")
+		}
+		s.Success(kustomizeMsg + "done!")
+	}
+
+	// Archive the sos report state. The sink is chosen based on the scheme
+	// of OutputArchive, e.g. a local tar.gz, a zip file, a plain directory
+	// for GitOps workflows, or an upload straight to S3 or GCS.
 	archiveMsg := "Report state... "
 	s, _ = upterm.CheckmarkSuccessSpinner.Start(archiveMsg)
-	if err = e.archive(ctx, fs, tmpDir); err != nil {
+	sink, err := NewArchiveSink(e.options)
+	if err != nil {
+		s.Fail(archiveMsg + "Failed!")
+		return errors.Wrap(err, "cannot configure archive sink")
+	}
+	if err = sink.Write(ctx, fs, tmpDir); err != nil {
 		s.Fail(archiveMsg + "Failed!")
 		return errors.Wrap(err, "cannot archive exported state")
 	}
@@ -170,8 +389,39 @@ func (e *ControlPlaneStateExporter) Export(ctx context.Context) error { // nolin
 }
 
 func (e *ControlPlaneStateExporter) shouldExport(in apiextensionsv1.CustomResourceDefinition) bool {
+	if !isCrossplaneManaged(in) {
+		return false
+	}
+
+	group := in.Spec.Group
+	gk := fmt.Sprintf("%s.%s", in.Spec.Names.Kind, group)
+
+	if len(e.options.IncludeGroups) > 0 && !matchesAnyPattern(e.options.IncludeGroups, group) {
+		return false
+	}
+	if len(e.options.IncludeGVKs) > 0 && !matchesAnyPattern(e.options.IncludeGVKs, gk) {
+		return false
+	}
+	if matchesAnyPattern(e.options.ExcludeGroups, group) {
+		return false
+	}
+	if matchesAnyPattern(e.options.ExcludeGVKs, gk) {
+		return false
+	}
+
+	return true
+}
+
+// isCrossplaneManaged reports whether in is a type we'd ever consider
+// exporting, independent of any user-supplied include/exclude filters:
+// - Crossplane Core CRDs - Has suffix ".crossplane.io".
+// - CRDs owned by Crossplane packages - Has owner reference to a Crossplane package.
+// - CRDs owned by a CompositeResourceDefinition - Has owner reference to a CompositeResourceDefinition.
+func isCrossplaneManaged(in apiextensionsv1.CustomResourceDefinition) bool {
 	for _, ref := range in.GetOwnerReferences() {
-		// Types owned by a Crossplane package.
+		if ref.APIVersion == "apiextensions.crossplane.io/v1" && ref.Kind == "CompositeResourceDefinition" {
+			return true
+		}
 		if ref.APIVersion == "pkg.crossplane.io/v1" {
 			return true
 		}
@@ -180,6 +430,17 @@ func (e *ControlPlaneStateExporter) shouldExport(in apiextensionsv1.CustomResour
 	return strings.HasSuffix(in.GetName(), ".crossplane.io")
 }
 
+// matchesAnyPattern reports whether s matches any of the given glob
+// patterns. A malformed pattern never matches.
+func matchesAnyPattern(patterns []string, s string) bool {
+	for _, p := range patterns {
+		if ok, _ := path.Match(p, s); ok {
+			return true
+		}
+	}
+	return false
+}
+
 func (e *ControlPlaneStateExporter) customResourceGVR(in apiextensionsv1.CustomResourceDefinition) (schema.GroupVersionResource, error) {
 	version := ""
 	for _, vr := range in.Spec.Versions {
@@ -200,34 +461,6 @@ func (e *ControlPlaneStateExporter) customResourceGVR(in apiextensionsv1.CustomR
 	return rm.Resource, nil
 }
 
-func (e *ControlPlaneStateExporter) archive(ctx context.Context, fs afero.Afero, dir string) error {
-	files, err := archiver.FilesFromDisk(nil, map[string]string{
-		dir + "/": "",
-	})
-	if err != nil {
-		return err
-	}
-
-	out, err := fs.Create(e.options.OutputArchive)
-	if err != nil {
-		return err
-	}
-	defer func() {
-		_ = out.Close()
-	}()
-
-	if err = fs.Chmod(e.options.OutputArchive, 0600); err != nil {
-		return err
-	}
-
-	format := archiver.CompressedArchive{
-		Compression: archiver.Gz{},
-		Archival:    archiver.Tar{},
-	}
-
-	return format.Archive(ctx, out, files)
-}
-
 func fetchAllCRDs(ctx context.Context, kube apiextensionsclientset.Interface) ([]apiextensionsv1.CustomResourceDefinition, error) {
 	var crds []apiextensionsv1.CustomResourceDefinition
 
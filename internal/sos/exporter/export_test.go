@@ -0,0 +1,125 @@
+// Copyright 2024 Upbound Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exporter
+
+import (
+	"testing"
+
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestMatchesAnyPattern(t *testing.T) {
+	cases := map[string]struct {
+		patterns []string
+		s        string
+		want     bool
+	}{
+		"NoPatterns":       {patterns: nil, s: "example.org", want: false},
+		"ExactMatch":       {patterns: []string{"example.org"}, s: "example.org", want: true},
+		"GlobMatch":        {patterns: []string{"*.example.org"}, s: "foo.example.org", want: true},
+		"NoMatch":          {patterns: []string{"*.example.org"}, s: "example.org", want: false},
+		"MalformedPattern": {patterns: []string{"["}, s: "example.org", want: false},
+		"SecondOfSeveral":  {patterns: []string{"foo.io", "*.example.org"}, s: "bar.example.org", want: true},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			if got := matchesAnyPattern(tc.patterns, tc.s); got != tc.want {
+				t.Errorf("matchesAnyPattern(%v, %q) = %t, want %t", tc.patterns, tc.s, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestIsCrossplaneManaged(t *testing.T) {
+	cases := map[string]struct {
+		crd  apiextensionsv1.CustomResourceDefinition
+		want bool
+	}{
+		"CoreCrossplaneSuffix": {
+			crd:  apiextensionsv1.CustomResourceDefinition{ObjectMeta: metav1.ObjectMeta{Name: "compositeresourcedefinitions.apiextensions.crossplane.io"}},
+			want: true,
+		},
+		"PackageOwned": {
+			crd: apiextensionsv1.CustomResourceDefinition{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:            "widgets.example.org",
+					OwnerReferences: []metav1.OwnerReference{{APIVersion: "pkg.crossplane.io/v1"}},
+				},
+			},
+			want: true,
+		},
+		"Unmanaged": {
+			crd:  apiextensionsv1.CustomResourceDefinition{ObjectMeta: metav1.ObjectMeta{Name: "widgets.example.org"}},
+			want: false,
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			if got := isCrossplaneManaged(tc.crd); got != tc.want {
+				t.Errorf("isCrossplaneManaged(%q) = %t, want %t", tc.crd.GetName(), got, tc.want)
+			}
+		})
+	}
+}
+
+func crossplaneCRD(name, group, kind string) apiextensionsv1.CustomResourceDefinition {
+	return apiextensionsv1.CustomResourceDefinition{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Spec: apiextensionsv1.CustomResourceDefinitionSpec{
+			Group: group,
+			Names: apiextensionsv1.CustomResourceDefinitionNames{Kind: kind},
+		},
+	}
+}
+
+func TestShouldExport(t *testing.T) {
+	crd := crossplaneCRD("widgets.example.crossplane.io", "example.crossplane.io", "Widget")
+
+	cases := map[string]struct {
+		opts Options
+		want bool
+	}{
+		"NoFilters":           {opts: Options{}, want: true},
+		"IncludeGroupMatches": {opts: Options{IncludeGroups: []string{"example.crossplane.io"}}, want: true},
+		"IncludeGroupMisses":  {opts: Options{IncludeGroups: []string{"other.crossplane.io"}}, want: false},
+		"ExcludeGroupMatches": {opts: Options{ExcludeGroups: []string{"example.crossplane.io"}}, want: false},
+		"IncludeGVKMatches":   {opts: Options{IncludeGVKs: []string{"Widget.example.crossplane.io"}}, want: true},
+		"ExcludeGVKMatches":   {opts: Options{ExcludeGVKs: []string{"Widget.*"}}, want: false},
+		"ExcludeWinsOverInclude": {
+			opts: Options{IncludeGroups: []string{"example.crossplane.io"}, ExcludeGVKs: []string{"Widget.*"}},
+			want: false,
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			e := &ControlPlaneStateExporter{options: tc.opts}
+			if got := e.shouldExport(crd); got != tc.want {
+				t.Errorf("shouldExport() = %t, want %t", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestShouldExportIgnoresNonCrossplaneCRDs(t *testing.T) {
+	crd := crossplaneCRD("widgets.example.org", "example.org", "Widget")
+	e := &ControlPlaneStateExporter{}
+	if e.shouldExport(crd) {
+		t.Error("shouldExport() = true for a CRD that isn't Crossplane-managed")
+	}
+}
@@ -0,0 +1,218 @@
+// Copyright 2024 Upbound Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exporter
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/crossplane/crossplane-runtime/pkg/errors"
+	"github.com/spf13/afero"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/yaml"
+
+	"github.com/upbound/up/internal/migration/meta/v1alpha1"
+)
+
+const (
+	// kustomizationFileName is the file name Kustomize expects at the root
+	// of every directory it treats as a base.
+	kustomizationFileName = "kustomization.yaml"
+
+	// typeMetaFileName is the per-type metadata file FileSystemPersister
+	// writes alongside each type's resource dump.
+	typeMetaFileName = "zz_type_meta.yaml"
+
+	// kustomizeDirName is the top-level directory, alongside resources/ and
+	// metadata.yaml, that the stripped overlay copies are written to.
+	kustomizeDirName = "kustomize"
+)
+
+// defaultStripFields are the server-populated fields removed from every
+// exported manifest when Options.EmitKustomize is enabled. They're either
+// meaningless outside the cluster that produced them (resourceVersion, uid,
+// generation, managedFields) or, for creationTimestamp, just noise in a
+// diff.
+var defaultStripFields = []string{
+	"metadata.resourceVersion",
+	"metadata.uid",
+	"metadata.generation",
+	"metadata.managedFields",
+	"metadata.creationTimestamp",
+}
+
+func (o Options) stripFields() []string {
+	if len(o.KustomizeStripFields) == 0 {
+		return defaultStripFields
+	}
+	return o.KustomizeStripFields
+}
+
+// emitKustomizeOverlay strips server-populated fields from every exported
+// manifest and writes the result under tmpDir/kustomize, alongside (not in
+// place of) the raw manifests under tmpDir/resources, with a
+// kustomization.yaml per resource group plus one at the overlay root that
+// composes them. This makes the export additionally renderable with
+// `kustomize build`, without touching the fidelity of the raw
+// disaster-recovery snapshot every ArchiveSink archives.
+func (e *ControlPlaneStateExporter) emitKustomizeOverlay(fs afero.Afero, tmpDir string) error {
+	resourcesDir := filepath.Join(tmpDir, "resources")
+	exists, err := fs.DirExists(resourcesDir)
+	if err != nil {
+		return errors.Wrap(err, "cannot stat resources directory")
+	}
+	if !exists {
+		return nil
+	}
+
+	kustomizeDir := filepath.Join(tmpDir, kustomizeDirName)
+
+	groupDirs, err := fs.ReadDir(resourcesDir)
+	if err != nil {
+		return errors.Wrap(err, "cannot list resource groups")
+	}
+
+	var bases []string
+	for _, gd := range groupDirs {
+		if !gd.IsDir() {
+			continue
+		}
+
+		srcDir := filepath.Join(resourcesDir, gd.Name())
+		dstDir := filepath.Join(kustomizeDir, gd.Name())
+		manifests, err := e.stripAndCollectManifests(fs, srcDir, dstDir)
+		if err != nil {
+			return errors.Wrapf(err, "cannot process group %q", gd.Name())
+		}
+		if len(manifests) == 0 {
+			continue
+		}
+
+		if err := writeKustomization(fs, dstDir, manifests); err != nil {
+			return errors.Wrapf(err, "cannot write kustomization for group %q", gd.Name())
+		}
+		bases = append(bases, gd.Name())
+	}
+	if len(bases) == 0 {
+		return nil
+	}
+
+	sort.Strings(bases)
+	return writeKustomization(fs, kustomizeDir, bases)
+}
+
+// stripAndCollectManifests reads every manifest under srcDir, strips
+// Options.stripFields (and status, for types that don't have a status
+// subresource) from a copy, and writes that copy under dstDir, mirroring
+// srcDir's layout. It returns the written manifests' paths relative to
+// dstDir. srcDir itself is never modified.
+func (e *ControlPlaneStateExporter) stripAndCollectManifests(fs afero.Afero, srcDir, dstDir string) ([]string, error) {
+	var manifests []string
+
+	err := fs.Walk(srcDir, func(path string, info os.FileInfo, walkErr error) error {
+		if walkErr != nil {
+			return walkErr
+		}
+		if info.IsDir() || filepath.Ext(path) != ".yaml" || filepath.Base(path) == typeMetaFileName {
+			return nil
+		}
+
+		// Best-effort: a type we can't find metadata for is stripped of
+		// status, since that's the safer default for a diff-friendly
+		// overlay.
+		typeMeta, _ := readTypeMetaForManifest(fs, path)
+
+		b, err := fs.ReadFile(path)
+		if err != nil {
+			return errors.Wrapf(err, "cannot read %q", path)
+		}
+		u := &unstructured.Unstructured{}
+		if err := yaml.Unmarshal(b, u); err != nil {
+			return errors.Wrapf(err, "cannot parse %q", path)
+		}
+
+		for _, field := range e.options.stripFields() {
+			unstructured.RemoveNestedField(u.Object, strings.Split(field, ".")...)
+		}
+		if typeMeta == nil || !typeMeta.WithStatusSubresource {
+			unstructured.RemoveNestedField(u.Object, "status")
+		}
+
+		out, err := yaml.Marshal(u.Object)
+		if err != nil {
+			return errors.Wrapf(err, "cannot marshal %q", path)
+		}
+
+		rel, err := filepath.Rel(srcDir, path)
+		if err != nil {
+			return err
+		}
+		dst := filepath.Join(dstDir, rel)
+		if err := fs.MkdirAll(filepath.Dir(dst), 0700); err != nil {
+			return errors.Wrapf(err, "cannot create %q", filepath.Dir(dst))
+		}
+		if err := fs.WriteFile(dst, out, 0600); err != nil {
+			return errors.Wrapf(err, "cannot write %q", dst)
+		}
+
+		manifests = append(manifests, rel)
+		return nil
+	})
+
+	sort.Strings(manifests)
+	return manifests, err
+}
+
+// readTypeMetaForManifest reads the type metadata of the manifest at
+// manifestPath. A cluster-scoped manifest sits beside its type's metadata
+// file; a namespaced one sits one level below it, in its namespace's
+// directory.
+func readTypeMetaForManifest(fs afero.Afero, manifestPath string) (*v1alpha1.TypeMeta, error) {
+	dir := filepath.Dir(manifestPath)
+	b, err := fs.ReadFile(filepath.Join(dir, typeMetaFileName))
+	if err != nil {
+		b, err = fs.ReadFile(filepath.Join(filepath.Dir(dir), typeMetaFileName))
+	}
+	if err != nil {
+		return nil, err
+	}
+	tm := &v1alpha1.TypeMeta{}
+	if err := yaml.Unmarshal(b, tm); err != nil {
+		return nil, err
+	}
+	return tm, nil
+}
+
+// kustomization is the minimal subset of the Kustomize config we emit.
+type kustomization struct {
+	APIVersion string   `json:"apiVersion"`
+	Kind       string   `json:"kind"`
+	Resources  []string `json:"resources"`
+}
+
+func writeKustomization(fs afero.Afero, dir string, resources []string) error {
+	k := kustomization{
+		APIVersion: "kustomize.config.k8s.io/v1beta1",
+		Kind:       "Kustomization",
+		Resources:  resources,
+	}
+	b, err := yaml.Marshal(k)
+	if err != nil {
+		return err
+	}
+	return fs.WriteFile(filepath.Join(dir, kustomizationFileName), b, 0600)
+}
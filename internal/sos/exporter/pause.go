@@ -0,0 +1,121 @@
+// Copyright 2024 Upbound Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exporter
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/crossplane/crossplane-runtime/pkg/errors"
+	"github.com/pterm/pterm"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// pausedAnnotation is the annotation Crossplane checks to decide whether it
+// should reconcile a managed resource.
+const pausedAnnotation = "crossplane.io/paused"
+
+// pauseResources patches pausedAnnotation onto every resource of gvr, so
+// that reconciliation doesn't cause drift while the resource is being
+// exported. Like the export fetch itself, it's scoped to
+// Options.IncludeNamespaces when that's set, so a namespace-scoped export
+// doesn't pause resources outside the namespaces it's actually exporting.
+// It returns the namespace/name of every resource it successfully paused;
+// resources it couldn't patch are logged as a warning rather than failing
+// the export, since a handful of unpausable resources shouldn't block an
+// otherwise successful SOS report.
+func (e *ControlPlaneStateExporter) pauseResources(ctx context.Context, gvr schema.GroupVersionResource) ([]string, error) {
+	if !e.options.PauseBeforeExport {
+		return nil, nil
+	}
+
+	namespaces := e.options.IncludeNamespaces
+	if len(namespaces) == 0 {
+		// "" lists across all namespaces (and is a no-op for cluster-scoped
+		// resources), preserving the unscoped behavior when no namespace
+		// filter is configured.
+		namespaces = []string{""}
+	}
+
+	var paused []string
+	var unpatchable []string
+	for _, ns := range namespaces {
+		p, u, err := e.pauseResourcesInNamespace(ctx, gvr, ns)
+		if err != nil {
+			return paused, err
+		}
+		paused = append(paused, p...)
+		unpatchable = append(unpatchable, u...)
+	}
+
+	if len(unpatchable) > 0 {
+		pterm.Warning.Printfln("could not pause %d resource(s) of %q before export, they may drift mid-export: %v", len(unpatchable), gvr.GroupResource(), unpatchable)
+	}
+
+	return paused, nil
+}
+
+func (e *ControlPlaneStateExporter) pauseResourcesInNamespace(ctx context.Context, gvr schema.GroupVersionResource, namespace string) (paused, unpatchable []string, err error) {
+	pageSize := e.options.PageSize
+	if pageSize <= 0 {
+		pageSize = defaultPageSize
+	}
+
+	continueToken := ""
+	for {
+		list, err := e.dynamicClient.Resource(gvr).Namespace(namespace).List(ctx, v1.ListOptions{
+			Limit:         pageSize,
+			Continue:      continueToken,
+			LabelSelector: e.options.LabelSelector,
+		})
+		if err != nil {
+			return paused, unpatchable, errors.Wrapf(err, "cannot list %q to pause", gvr.GroupResource())
+		}
+
+		for _, item := range list.Items {
+			if err := e.pauseResource(ctx, gvr, item); err != nil {
+				unpatchable = append(unpatchable, resourceKey(item))
+				continue
+			}
+			paused = append(paused, resourceKey(item))
+		}
+
+		continueToken = list.GetContinue()
+		if continueToken == "" {
+			break
+		}
+	}
+
+	return paused, unpatchable, nil
+}
+
+func (e *ControlPlaneStateExporter) pauseResource(ctx context.Context, gvr schema.GroupVersionResource, item unstructured.Unstructured) error {
+	_, err := e.dynamicClient.Resource(gvr).Namespace(item.GetNamespace()).Patch(ctx, item.GetName(), types.MergePatchType, patchAnnotationBody(pausedAnnotation, "true"), v1.PatchOptions{})
+	return err
+}
+
+func patchAnnotationBody(key, value string) []byte {
+	return []byte(fmt.Sprintf(`{"metadata":{"annotations":{%q:%q}}}`, key, value))
+}
+
+func resourceKey(item unstructured.Unstructured) string {
+	if ns := item.GetNamespace(); ns != "" {
+		return ns + "/" + item.GetName()
+	}
+	return item.GetName()
+}
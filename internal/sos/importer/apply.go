@@ -0,0 +1,197 @@
+// Copyright 2024 Upbound Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package importer
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/crossplane/crossplane-runtime/pkg/errors"
+	"github.com/spf13/afero"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/discovery"
+	"sigs.k8s.io/yaml"
+
+	"github.com/upbound/up/internal/migration/meta/v1alpha1"
+)
+
+const (
+	// healthyPollInterval is how often Import polls a package's status
+	// while waiting for it to become Healthy.
+	healthyPollInterval = 2 * time.Second
+	// healthyTimeout is the maximum time Import waits for a package to
+	// become Healthy before giving up.
+	healthyTimeout = 5 * time.Minute
+)
+
+// applyResources walks the exported manifests for gvr under dir and
+// server-side applies each one that hasn't already been recorded in the
+// checkpoint. Resources are applied with the spec/metadata payload first;
+// the status subresource is restored afterwards, and only for types the
+// exporter recorded as having one.
+func (i *ControlPlaneStateImporter) applyResources(ctx context.Context, fs afero.Afero, dir string, gvr schema.GroupVersionResource, cp *checkpoint) error {
+	typeMeta, err := readTypeMeta(fs, dir, gvr)
+	if err != nil {
+		return errors.Wrapf(err, "cannot read type metadata for %q", gvr.GroupResource())
+	}
+
+	resourceDir := filepath.Join(dir, "resources", gvr.Group, gvr.Version, gvr.Resource)
+	exists, err := fs.DirExists(resourceDir)
+	if err != nil {
+		return errors.Wrapf(err, "cannot stat resource directory for %q", gvr.GroupResource())
+	}
+	if !exists {
+		// Nothing was exported for this type.
+		return nil
+	}
+
+	return fs.Walk(resourceDir, func(path string, info os.FileInfo, walkErr error) error {
+		if walkErr != nil {
+			return walkErr
+		}
+		if info.IsDir() || filepath.Ext(path) != ".yaml" || filepath.Base(path) == typeMetaFileName {
+			return nil
+		}
+
+		rel, err := filepath.Rel(resourceDir, path)
+		if err != nil {
+			return err
+		}
+		namespace, name := splitResourcePath(rel)
+		if cp.has(gvr, namespace, name) {
+			return nil
+		}
+
+		b, err := fs.ReadFile(path)
+		if err != nil {
+			return errors.Wrapf(err, "cannot read %q", path)
+		}
+		u := &unstructured.Unstructured{}
+		if err := yaml.Unmarshal(b, u); err != nil {
+			return errors.Wrapf(err, "cannot parse %q", path)
+		}
+		if err := i.decryptResource(u, typeMeta); err != nil {
+			return errors.Wrapf(err, "cannot decrypt %q", path)
+		}
+
+		status, hasStatus, err := unstructured.NestedMap(u.Object, "status")
+		if err != nil {
+			return errors.Wrapf(err, "cannot extract status for %q", path)
+		}
+		if typeMeta.WithStatusSubresource {
+			unstructured.RemoveNestedField(u.Object, "status")
+		}
+
+		if err := i.serverSideApply(ctx, gvr, namespace, u); err != nil {
+			return errors.Wrapf(err, "cannot apply %q", path)
+		}
+
+		if typeMeta.WithStatusSubresource && hasStatus {
+			u.Object["status"] = status
+			if err := i.serverSideApplyStatus(ctx, gvr, namespace, u); err != nil {
+				return errors.Wrapf(err, "cannot apply status for %q", path)
+			}
+		}
+
+		cp.mark(gvr, namespace, name)
+		return nil
+	})
+}
+
+func (i *ControlPlaneStateImporter) serverSideApply(ctx context.Context, gvr schema.GroupVersionResource, namespace string, u *unstructured.Unstructured) error {
+	_, err := i.dynamicClient.Resource(gvr).Namespace(namespace).Apply(ctx, u.GetName(), u, metav1.ApplyOptions{FieldManager: i.options.FieldManager, Force: true})
+	return err
+}
+
+func (i *ControlPlaneStateImporter) serverSideApplyStatus(ctx context.Context, gvr schema.GroupVersionResource, namespace string, u *unstructured.Unstructured) error {
+	_, err := i.dynamicClient.Resource(gvr).Namespace(namespace).Apply(ctx, u.GetName(), u, metav1.ApplyOptions{FieldManager: i.options.FieldManager, Force: true}, "status")
+	return err
+}
+
+func readTypeMeta(fs afero.Afero, dir string, gvr schema.GroupVersionResource) (*v1alpha1.TypeMeta, error) {
+	path := filepath.Join(dir, "resources", gvr.Group, gvr.Version, gvr.Resource, typeMetaFileName)
+	b, err := fs.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	tm := &v1alpha1.TypeMeta{}
+	if err := yaml.Unmarshal(b, tm); err != nil {
+		return nil, err
+	}
+	return tm, nil
+}
+
+// splitResourcePath splits a path relative to a type's resource directory
+// into its namespace (empty for cluster-scoped resources) and name, undoing
+// the layout FileSystemPersister writes: "<namespace>/<name>.yaml" for
+// namespaced resources, "<name>.yaml" for cluster-scoped ones.
+func splitResourcePath(rel string) (namespace, name string) {
+	dir, file := filepath.Split(rel)
+	name = file[:len(file)-len(filepath.Ext(file))]
+	if dir == "" {
+		return "", name
+	}
+	return filepath.Clean(dir), name
+}
+
+// waitForPackageHealthy polls gvr until every instance reports a "Healthy" status
+// condition of "True", ctx is cancelled, or healthyTimeout elapses.
+func (i *ControlPlaneStateImporter) waitForPackageHealthy(ctx context.Context, gvr schema.GroupVersionResource) error {
+	ctx, cancel := context.WithTimeout(ctx, healthyTimeout)
+	defer cancel()
+
+	return wait.PollImmediateUntil(healthyPollInterval, func() (bool, error) {
+		list, err := i.dynamicClient.Resource(gvr).List(ctx, metav1.ListOptions{})
+		if err != nil {
+			return false, err
+		}
+		for _, item := range list.Items {
+			if !isHealthy(item) {
+				return false, nil
+			}
+		}
+		return true, nil
+	}, ctx.Done())
+}
+
+func isHealthy(u unstructured.Unstructured) bool {
+	conditions, found, err := unstructured.NestedSlice(u.Object, "status", "conditions")
+	if err != nil || !found {
+		return false
+	}
+	for _, c := range conditions {
+		cond, ok := c.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if cond["type"] == "Healthy" && cond["status"] == "True" {
+			return true
+		}
+	}
+	return false
+}
+
+func fetchCrossplaneVersion(disc discovery.DiscoveryInterface) (string, error) {
+	info, err := disc.ServerVersion()
+	if err != nil {
+		return "", err
+	}
+	return info.GitVersion, nil
+}
@@ -0,0 +1,97 @@
+// Copyright 2024 Upbound Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package importer
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"sync"
+
+	"github.com/crossplane/crossplane-runtime/pkg/errors"
+	"github.com/spf13/afero"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// checkpoint tracks which resources have already been applied in a
+// previous run of Import, so that a re-run after a partial failure resumes
+// instead of re-applying everything from scratch.
+type checkpoint struct {
+	mu      sync.Mutex
+	applied map[string]bool
+}
+
+// loadCheckpoint reads the checkpoint file from dir, if one exists. A
+// missing file is treated as an empty checkpoint rather than an error, since
+// that's the normal case for a fresh import.
+func loadCheckpoint(fs afero.Afero, dir string) (*checkpoint, error) {
+	c := &checkpoint{applied: map[string]bool{}}
+
+	path := filepath.Join(dir, checkpointFileName)
+	exists, err := fs.Exists(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot stat checkpoint file")
+	}
+	if !exists {
+		return c, nil
+	}
+
+	b, err := fs.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot read checkpoint file")
+	}
+	var applied []string
+	if err := json.Unmarshal(b, &applied); err != nil {
+		return nil, errors.Wrap(err, "cannot parse checkpoint file")
+	}
+	for _, k := range applied {
+		c.applied[k] = true
+	}
+	return c, nil
+}
+
+// save persists the checkpoint to dir.
+func (c *checkpoint) save(fs afero.Afero, dir string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	applied := make([]string, 0, len(c.applied))
+	for k := range c.applied {
+		applied = append(applied, k)
+	}
+	b, err := json.Marshal(applied)
+	if err != nil {
+		return errors.Wrap(err, "cannot marshal checkpoint")
+	}
+	return afero.WriteFile(fs, filepath.Join(dir, checkpointFileName), b, 0600)
+}
+
+// has reports whether the given resource has already been applied.
+func (c *checkpoint) has(gvr schema.GroupVersionResource, namespace, name string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.applied[checkpointKey(gvr, namespace, name)]
+}
+
+// mark records that the given resource has been applied.
+func (c *checkpoint) mark(gvr schema.GroupVersionResource, namespace, name string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.applied[checkpointKey(gvr, namespace, name)] = true
+}
+
+func checkpointKey(gvr schema.GroupVersionResource, namespace, name string) string {
+	return fmt.Sprintf("%s/%s/%s/%s", gvr.GroupResource(), gvr.Version, namespace, name)
+}
@@ -0,0 +1,81 @@
+// Copyright 2024 Upbound Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package importer
+
+import (
+	"testing"
+
+	"github.com/spf13/afero"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func TestCheckpointMarkAndHas(t *testing.T) {
+	gvr := schema.GroupVersionResource{Group: "example.org", Version: "v1", Resource: "widgets"}
+
+	c := &checkpoint{applied: map[string]bool{}}
+	if c.has(gvr, "default", "a") {
+		t.Fatal("has() = true for a resource that was never marked")
+	}
+
+	c.mark(gvr, "default", "a")
+	if !c.has(gvr, "default", "a") {
+		t.Fatal("has() = false for a resource that was marked")
+	}
+	if c.has(gvr, "default", "b") {
+		t.Fatal("has() = true for a different resource in the same namespace")
+	}
+	if c.has(gvr, "other", "a") {
+		t.Fatal("has() = true for the same name in a different namespace")
+	}
+}
+
+func TestLoadCheckpointMissingFile(t *testing.T) {
+	fs := afero.Afero{Fs: afero.NewMemMapFs()}
+
+	c, err := loadCheckpoint(fs, "/work")
+	if err != nil {
+		t.Fatalf("loadCheckpoint() returned an error for a missing file: %v", err)
+	}
+	if len(c.applied) != 0 {
+		t.Fatalf("loadCheckpoint() on a missing file = %v, want empty", c.applied)
+	}
+}
+
+func TestCheckpointSaveAndLoadRoundTrip(t *testing.T) {
+	fs := afero.Afero{Fs: afero.NewMemMapFs()}
+	gvr := schema.GroupVersionResource{Group: "example.org", Version: "v1", Resource: "widgets"}
+
+	c := &checkpoint{applied: map[string]bool{}}
+	c.mark(gvr, "default", "a")
+	c.mark(gvr, "", "cluster-scoped")
+
+	if err := c.save(fs, "/work"); err != nil {
+		t.Fatalf("save() returned an error: %v", err)
+	}
+
+	loaded, err := loadCheckpoint(fs, "/work")
+	if err != nil {
+		t.Fatalf("loadCheckpoint() returned an error: %v", err)
+	}
+	if !loaded.has(gvr, "default", "a") {
+		t.Fatal("loaded checkpoint is missing a resource that was saved")
+	}
+	if !loaded.has(gvr, "", "cluster-scoped") {
+		t.Fatal("loaded checkpoint is missing a cluster-scoped resource that was saved")
+	}
+	if loaded.has(gvr, "default", "never-marked") {
+		t.Fatal("loaded checkpoint has a resource that was never marked")
+	}
+}
@@ -0,0 +1,348 @@
+// Copyright 2024 Upbound Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package importer implements the counterpart of the sos exporter: it
+// unarchives a previously exported control plane state and re-applies it to
+// a (possibly different) Crossplane control plane.
+package importer
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"filippo.io/age"
+	"github.com/crossplane/crossplane-runtime/pkg/errors"
+	"github.com/mholt/archiver/v4"
+	"github.com/pterm/pterm"
+	"github.com/spf13/afero"
+	apiextensionsclientset "k8s.io/apiextensions-apiserver/pkg/client/clientset/clientset"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/dynamic"
+
+	"github.com/upbound/up/internal/migration/meta/v1alpha1"
+	"github.com/upbound/up/internal/upterm"
+)
+
+const (
+	// defaultFieldManager is the field manager used for the server-side
+	// apply calls made while importing.
+	defaultFieldManager = "up-import"
+
+	// checkpointFileName is the name of the file, relative to the working
+	// directory, that tracks which resources have already been applied so
+	// that a re-run after a partial failure can resume where it left off.
+	checkpointFileName = ".checkpoint.json"
+
+	// metadataFileName is the top level metadata file written by the
+	// exporter that describes the Crossplane version, feature flags and
+	// per-type resource counts of an export.
+	metadataFileName = "metadata.yaml"
+
+	// typeMetaFileName is the per-type metadata file the exporter writes
+	// alongside each type's resource dump.
+	typeMetaFileName = "zz_type_meta.yaml"
+)
+
+// Options for the importer.
+type Options struct {
+	// InputArchive is the path to the archive file produced by the
+	// exporter that should be imported.
+	InputArchive string
+
+	// FieldManager is the field manager used when server-side applying
+	// resources. Defaults to defaultFieldManager.
+	FieldManager string
+
+	// WorkDir is the directory the archive is unpacked into and the
+	// checkpoint file is kept in. If empty, a stable directory derived from
+	// InputArchive, under os.TempDir(), is used instead: it's removed once
+	// the import completes successfully, but left in place on failure so a
+	// re-run with the same InputArchive finds its checkpoint and resumes.
+	WorkDir string
+
+	// UnpauseAfterImport removes the crossplane.io/paused annotation from
+	// every resource the exporter recorded as having paused, once the full
+	// state has been reapplied and all packages are Healthy. It's the
+	// counterpart of exporter.Options.PauseBeforeExport.
+	UnpauseAfterImport bool
+
+	// AgeIdentities are the age private keys used to decrypt fields the
+	// exporter encrypted. Required if and only if the archive being
+	// imported was produced with exporter.Options.Encryption enabled; a
+	// resource with encrypted fields and no matching identity fails to
+	// import.
+	AgeIdentities []string
+}
+
+// ControlPlaneStateImporter imports a previously exported Crossplane control
+// plane state.
+type ControlPlaneStateImporter struct {
+	crdClient       apiextensionsclientset.Interface
+	dynamicClient   dynamic.Interface
+	discoveryClient discovery.DiscoveryInterface
+	resourceMapper  meta.RESTMapper
+	options         Options
+
+	identities []age.Identity
+}
+
+// NewControlPlaneStateImporter returns a new ControlPlaneStateImporter.
+func NewControlPlaneStateImporter(crdClient apiextensionsclientset.Interface, dynamicClient dynamic.Interface, discoveryClient discovery.DiscoveryInterface, mapper meta.RESTMapper, opts Options) (*ControlPlaneStateImporter, error) {
+	if opts.FieldManager == "" {
+		opts.FieldManager = defaultFieldManager
+	}
+
+	identities := make([]age.Identity, 0, len(opts.AgeIdentities))
+	for _, id := range opts.AgeIdentities {
+		identity, err := age.ParseX25519Identity(id)
+		if err != nil {
+			return nil, errors.Wrap(err, "cannot parse age identity")
+		}
+		identities = append(identities, identity)
+	}
+
+	return &ControlPlaneStateImporter{
+		crdClient:       crdClient,
+		dynamicClient:   dynamicClient,
+		discoveryClient: discoveryClient,
+		resourceMapper:  mapper,
+		options:         opts,
+		identities:      identities,
+	}, nil
+}
+
+// Import unarchives the configured input archive and applies its resources
+// to the control plane in dependency order: CRDs and XRDs first, then
+// Providers and Configurations (waiting for each to become Healthy), then
+// claims and composites, then all remaining managed resources. Resources
+// that were already applied in a previous, interrupted run are skipped on
+// resume.
+func (i *ControlPlaneStateImporter) Import(ctx context.Context) error { // nolint:gocyclo
+	pterm.EnableStyling()
+
+	pterm.Println("Starting control plane import...")
+
+	fs := afero.Afero{Fs: afero.NewOsFs()}
+	workDir := i.options.WorkDir
+	ownWorkDir := workDir == ""
+	if ownWorkDir {
+		d, err := defaultWorkDir(i.options.InputArchive)
+		if err != nil {
+			return errors.Wrap(err, "cannot determine working directory")
+		}
+		workDir = d
+	}
+	if err := fs.MkdirAll(workDir, 0700); err != nil {
+		return errors.Wrap(err, "cannot create working directory")
+	}
+
+	// Only remove a working directory we created ourselves, and only once
+	// the import has fully succeeded: on failure it's left in place, along
+	// with the checkpoint inside it, so a re-run with the same
+	// InputArchive resumes instead of starting over.
+	succeeded := false
+	defer func() {
+		if succeeded && ownWorkDir {
+			_ = fs.RemoveAll(workDir)
+		}
+	}()
+
+	unarchiveMsg := "Unpacking import archive... "
+	s, _ := upterm.CheckmarkSuccessSpinner.Start(unarchiveMsg)
+	if err := i.unarchive(ctx, fs, workDir); err != nil {
+		s.Fail(unarchiveMsg + "Failed!")
+		return errors.Wrap(err, "cannot unarchive input")
+	}
+	s.Success(unarchiveMsg + "done!")
+
+	compatMsg := "Checking compatibility... "
+	s, _ = upterm.CheckmarkSuccessSpinner.Start(compatMsg)
+	md, err := i.readMetadata(fs, workDir)
+	if err != nil {
+		s.Fail(compatMsg + "Failed!")
+		return errors.Wrap(err, "cannot read export metadata")
+	}
+	if err := i.checkCompatibility(md); err != nil {
+		s.Fail(compatMsg + "Failed!")
+		return errors.Wrap(err, "exported state is not compatible with this control plane")
+	}
+	s.Success(compatMsg + "compatible!")
+
+	cp, err := loadCheckpoint(fs, workDir)
+	if err != nil {
+		return errors.Wrap(err, "cannot load checkpoint")
+	}
+	defer func() {
+		_ = cp.save(fs, workDir)
+	}()
+
+	stages := []struct {
+		name        string
+		gvrs        []schema.GroupVersionResource
+		waitHealthy bool
+	}{
+		{name: "CRDs and XRDs", gvrs: i.crdAndXRDGVRs(md)},
+		{name: "Providers and Configurations", gvrs: i.packageGVRs(md), waitHealthy: true},
+		{name: "claims and composites", gvrs: i.compositeGVRs(md)},
+		{name: "managed resources", gvrs: i.managedResourceGVRs(md)},
+	}
+
+	for _, stage := range stages {
+		msg := fmt.Sprintf("Applying %s... ", stage.name)
+		s, _ = upterm.CheckmarkSuccessSpinner.Start(msg)
+		for _, gvr := range stage.gvrs {
+			if err := i.applyResources(ctx, fs, workDir, gvr, cp); err != nil {
+				s.Fail(msg + "Failed!")
+				return errors.Wrapf(err, "cannot apply %q", gvr.GroupResource())
+			}
+			if stage.waitHealthy {
+				if err := i.waitForPackageHealthy(ctx, gvr); err != nil {
+					s.Fail(msg + "Failed!")
+					return errors.Wrapf(err, "%q did not become healthy", gvr.GroupResource())
+				}
+			}
+			if err := cp.save(fs, workDir); err != nil {
+				s.Fail(msg + "Failed!")
+				return errors.Wrap(err, "cannot persist checkpoint")
+			}
+		}
+		s.Success(msg + "done!")
+	}
+
+	if i.options.UnpauseAfterImport {
+		unpauseMsg := "Unpausing restored resources... "
+		s, _ = upterm.CheckmarkSuccessSpinner.Start(unpauseMsg)
+		if err := i.unpauseResources(ctx, md); err != nil {
+			s.Fail(unpauseMsg + "Failed!")
+			return errors.Wrap(err, "cannot unpause restored resources")
+		}
+		s.Success(unpauseMsg + "done!")
+	}
+
+	pterm.Println("\nSuccessfully imported control plane state!")
+	succeeded = true
+	return nil
+}
+
+// defaultWorkDir returns a stable, deterministic working directory for
+// inputArchive, so that re-running Import with the same InputArchive and no
+// explicit WorkDir finds the same directory, and therefore the same
+// checkpoint, as a previous failed attempt.
+func defaultWorkDir(inputArchive string) (string, error) {
+	abs, err := filepath.Abs(inputArchive)
+	if err != nil {
+		return "", errors.Wrapf(err, "cannot resolve %q", inputArchive)
+	}
+	sum := sha256.Sum256([]byte(abs))
+	return filepath.Join(os.TempDir(), "up-import-"+hex.EncodeToString(sum[:8])), nil
+}
+
+func (i *ControlPlaneStateImporter) unarchive(ctx context.Context, fs afero.Afero, dir string) error {
+	in, err := fs.Open(i.options.InputArchive)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = in.Close()
+	}()
+
+	format := archiver.CompressedArchive{
+		Compression: archiver.Gz{},
+		Archival:    archiver.Tar{},
+	}
+
+	return format.Extract(ctx, in, nil, func(_ context.Context, f archiver.FileInfo) error {
+		target, err := safeJoin(dir, f.NameInArchive)
+		if err != nil {
+			return errors.Wrapf(err, "refusing to extract %q", f.NameInArchive)
+		}
+		if f.IsDir() {
+			return fs.MkdirAll(target, 0700)
+		}
+		if err := fs.MkdirAll(filepath.Dir(target), 0700); err != nil {
+			return err
+		}
+		src, err := f.Open()
+		if err != nil {
+			return err
+		}
+		defer func() {
+			_ = src.Close()
+		}()
+		b, err := ioutil.ReadAll(src)
+		if err != nil {
+			return err
+		}
+		return fs.WriteFile(target, b, 0600)
+	})
+}
+
+// safeJoin joins dir and name, the latter a path read from an archive
+// entry, and rejects the result if it would escape dir (a "tar-slip" via a
+// "../" or absolute path in name). An SOS archive may be passed around a
+// support ticket and could be malformed or malicious.
+func safeJoin(dir, name string) (string, error) {
+	target := filepath.Join(dir, name)
+	if target != dir && !strings.HasPrefix(target, dir+string(filepath.Separator)) {
+		return "", errors.Errorf("%q escapes the archive root", name)
+	}
+	return target, nil
+}
+
+func (i *ControlPlaneStateImporter) readMetadata(fs afero.Afero, dir string) (*v1alpha1.ExportMetadata, error) {
+	b, err := fs.ReadFile(filepath.Join(dir, metadataFileName))
+	if err != nil {
+		return nil, err
+	}
+	return v1alpha1.ParseExportMetadata(b)
+}
+
+func (i *ControlPlaneStateImporter) checkCompatibility(md *v1alpha1.ExportMetadata) error {
+	current, err := fetchCrossplaneVersion(i.discoveryClient)
+	if err != nil {
+		return errors.Wrap(err, "cannot determine current Crossplane version")
+	}
+	if !md.IsCompatibleWith(current) {
+		return errors.Errorf("export was produced by Crossplane %q, which is incompatible with the running version %q", md.CrossplaneVersion, current)
+	}
+	return nil
+}
+
+// crdAndXRDGVRs, packageGVRs, compositeGVRs and managedResourceGVRs partition
+// the types recorded in the export metadata into the apply order Import
+// uses, based on the `categories` each type's CRD carries (recorded in its
+// TypeMeta.Categories at export time).
+func (i *ControlPlaneStateImporter) crdAndXRDGVRs(md *v1alpha1.ExportMetadata) []schema.GroupVersionResource {
+	return md.GVRsWithCategory("crd", "xrd")
+}
+
+func (i *ControlPlaneStateImporter) packageGVRs(md *v1alpha1.ExportMetadata) []schema.GroupVersionResource {
+	return md.GVRsWithCategory("provider", "configuration")
+}
+
+func (i *ControlPlaneStateImporter) compositeGVRs(md *v1alpha1.ExportMetadata) []schema.GroupVersionResource {
+	return md.GVRsWithCategory("claim", "composite")
+}
+
+func (i *ControlPlaneStateImporter) managedResourceGVRs(md *v1alpha1.ExportMetadata) []schema.GroupVersionResource {
+	return md.GVRsWithCategory("managed")
+}
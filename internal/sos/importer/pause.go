@@ -0,0 +1,90 @@
+// Copyright 2024 Upbound Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package importer
+
+import (
+	"context"
+	"strings"
+
+	"github.com/crossplane/crossplane-runtime/pkg/errors"
+	"github.com/pterm/pterm"
+	"k8s.io/apimachinery/pkg/api/meta"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+
+	"github.com/upbound/up/internal/migration/meta/v1alpha1"
+)
+
+// pausedAnnotation is the annotation Crossplane checks to decide whether it
+// should reconcile a managed resource. It mirrors exporter.pausedAnnotation.
+const pausedAnnotation = "crossplane.io/paused"
+
+// unpauseResources removes pausedAnnotation from every resource the export
+// metadata recorded as having been paused before export. A resource that
+// can no longer be found (e.g. it was removed upstream) is skipped rather
+// than failing the import.
+func (i *ControlPlaneStateImporter) unpauseResources(ctx context.Context, md *v1alpha1.ExportMetadata) error {
+	var unpatchable []string
+
+	for gr, keys := range md.PausedResources {
+		gvr, err := gvrForGroupResource(i.resourceMapper, gr)
+		if err != nil {
+			return errors.Wrapf(err, "cannot resolve GVR for %q", gr)
+		}
+
+		for _, key := range keys {
+			namespace, name := splitResourceKey(key)
+			_, err := i.dynamicClient.Resource(gvr).Namespace(namespace).Patch(ctx, name, types.JSONPatchType, removeAnnotationPatch(pausedAnnotation), v1.PatchOptions{})
+			if err != nil {
+				unpatchable = append(unpatchable, gr+"/"+key)
+			}
+		}
+	}
+
+	if len(unpatchable) > 0 {
+		pterm.Warning.Printfln("could not unpause %d resource(s), they may remain paused: %v", len(unpatchable), unpatchable)
+	}
+
+	return nil
+}
+
+func removeAnnotationPatch(key string) []byte {
+	return []byte(`[{"op":"remove","path":"/metadata/annotations/` + jsonPatchEscape(key) + `"}]`)
+}
+
+// jsonPatchEscape escapes "/" and "~" per RFC 6901 so an annotation name
+// like "crossplane.io/paused" is a valid JSON Patch path segment.
+func jsonPatchEscape(s string) string {
+	s = strings.ReplaceAll(s, "~", "~0")
+	return strings.ReplaceAll(s, "/", "~1")
+}
+
+func splitResourceKey(key string) (namespace, name string) {
+	if idx := strings.LastIndex(key, "/"); idx >= 0 {
+		return key[:idx], key[idx+1:]
+	}
+	return "", key
+}
+
+// gvrForGroupResource resolves a "resource.group" string, as recorded in
+// export metadata, to a concrete GroupVersionResource.
+func gvrForGroupResource(mapper meta.RESTMapper, groupResource string) (schema.GroupVersionResource, error) {
+	resource, group := groupResource, ""
+	if idx := strings.Index(groupResource, "."); idx >= 0 {
+		resource, group = groupResource[:idx], groupResource[idx+1:]
+	}
+	return mapper.ResourceFor(schema.GroupVersionResource{Group: group, Resource: resource})
+}